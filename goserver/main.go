@@ -1,8 +1,170 @@
 package main
 
-import "workspaceApi/pkg/server"
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"workspaceApi/pkg/server"
+)
+
+// tracingShutdownTimeout bounds how long we try to flush pending spans on exit.
+const tracingShutdownTimeout = 5 * time.Second
+
+/*
+setupTracing exports traces via OTLP/HTTP if `OTEL_EXPORTER_OTLP_ENDPOINT`
+is set.
+
+Incoming W3C `traceparent` and `baggage` headers are always honoured so
+that requests join the trace of the caller.
+
+Returns a function that flushes the pending spans.
+*/
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The exporter reads the endpoint and options from the environment.
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+/* envList splits the comma separated values in environment variable `name`.*/
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+/* envInt parses the integer in environment variable `name`, or returns 0 if unset.*/
+func envInt(name string) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return n, nil
+}
+
+/* envDuration parses the duration, eg "30s", in environment variable `name`, or returns 0 if unset.*/
+func envDuration(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return d, nil
+}
+
+/*
+loadConfig builds the server configuration from the `DFH_*` environment
+variables. Unset variables leave the corresponding defaults in place.
+*/
+func loadConfig(logger *slog.Logger) (server.Config, error) {
+	cfg := server.Config{
+		Logger:         logger,
+		TLSCertFile:    os.Getenv("DFH_TLS_CERT_FILE"),
+		TLSKeyFile:     os.Getenv("DFH_TLS_KEY_FILE"),
+		AuthToken:      os.Getenv("DFH_AUTH_TOKEN"),
+		BasePath:       os.Getenv("DFH_BASE_PATH"),
+		AllowedOrigins: envList("DFH_ALLOWED_ORIGINS"),
+		TrustedProxies: envList("DFH_TRUSTED_PROXIES"),
+	}
+
+	var err error
+	if cfg.RateLimit, err = envInt("DFH_RATE_LIMIT"); err != nil {
+		return cfg, err
+	}
+	if cfg.RequestTimeout, err = envDuration("DFH_REQUEST_TIMEOUT"); err != nil {
+		return cfg, err
+	}
+	if cfg.GzipLevel, err = envInt("DFH_GZIP_LEVEL"); err != nil {
+		return cfg, err
+	}
+	if cfg.GzipMinSize, err = envInt("DFH_GZIP_MIN_SIZE"); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+/*
+run serves the API until the process receives SIGINT or SIGTERM.
+
+The first signal triggers a graceful shutdown, a second one terminates the
+process immediately.
+*/
+func run(logger *slog.Logger) error {
+	cfg, err := loadConfig(logger)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Restore the default signal handling once the shutdown has begun.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot set up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("cannot flush traces", "error", err)
+		}
+	}()
+
+	return server.Run(ctx, cfg, "0.0.0.0:5002")
+}
 
 func main() {
-	router := server.SetupRouter()
-	router.Run("0.0.0.0:5002")
+	// Gin prints a banner and the routes to stdout in debug mode, which would
+	// interleave with the JSON logs.
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if err := run(logger); err != nil {
+		logger.Error("server failed", "error", err)
+		os.Exit(1)
+	}
 }
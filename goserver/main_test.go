@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"workspaceApi/pkg/server"
+)
+
+// configEnv lists the environment variables read by `loadConfig`.
+var configEnv = []string{
+	"DFH_ALLOWED_ORIGINS", "DFH_TLS_CERT_FILE", "DFH_TLS_KEY_FILE", "DFH_AUTH_TOKEN",
+	"DFH_GZIP_LEVEL", "DFH_GZIP_MIN_SIZE", "DFH_RATE_LIMIT", "DFH_TRUSTED_PROXIES",
+	"DFH_BASE_PATH", "DFH_REQUEST_TIMEOUT",
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		origins   []string
+		gzipLevel int
+		rateLimit int
+		proxies   []string
+		timeout   time.Duration
+		isValid   bool
+	}{
+		{name: "unset", env: nil, origins: nil, isValid: true},
+		{
+			name:    "origins",
+			env:     map[string]string{"DFH_ALLOWED_ORIGINS": "http://a.example.com,https://b.example.com"},
+			origins: []string{"http://a.example.com", "https://b.example.com"},
+			isValid: true,
+		},
+		{
+			name:    "origins with whitespace and empty entries",
+			env:     map[string]string{"DFH_ALLOWED_ORIGINS": " http://a.example.com , ,https://b.example.com,"},
+			origins: []string{"http://a.example.com", "https://b.example.com"},
+			isValid: true,
+		},
+		{name: "only separators", env: map[string]string{"DFH_ALLOWED_ORIGINS": " , "}, origins: nil, isValid: true},
+		{name: "origin without scheme", env: map[string]string{"DFH_ALLOWED_ORIGINS": "a.example.com"}, isValid: false},
+		{name: "wildcard origin", env: map[string]string{"DFH_ALLOWED_ORIGINS": "*"}, isValid: false},
+		{name: "gzip level", env: map[string]string{"DFH_GZIP_LEVEL": "9"}, gzipLevel: 9, isValid: true},
+		{name: "gzip huffman only", env: map[string]string{"DFH_GZIP_LEVEL": "-2"}, gzipLevel: -2, isValid: true},
+		{name: "gzip level too low", env: map[string]string{"DFH_GZIP_LEVEL": "-3"}, isValid: false},
+		{name: "gzip level too high", env: map[string]string{"DFH_GZIP_LEVEL": "10"}, isValid: false},
+		{name: "gzip level not a number", env: map[string]string{"DFH_GZIP_LEVEL": "best"}, isValid: false},
+		{name: "gzip min size not a number", env: map[string]string{"DFH_GZIP_MIN_SIZE": "1k"}, isValid: false},
+		{name: "rate limit", env: map[string]string{"DFH_RATE_LIMIT": "100"}, rateLimit: 100, isValid: true},
+		{name: "rate limit not a number", env: map[string]string{"DFH_RATE_LIMIT": "100/min"}, isValid: false},
+		{name: "negative rate limit", env: map[string]string{"DFH_RATE_LIMIT": "-1"}, isValid: false},
+		{
+			name:    "trusted proxies",
+			env:     map[string]string{"DFH_TRUSTED_PROXIES": "10.0.0.1, 192.168.0.0/16,"},
+			proxies: []string{"10.0.0.1", "192.168.0.0/16"},
+			isValid: true,
+		},
+		{name: "invalid trusted proxy", env: map[string]string{"DFH_TRUSTED_PROXIES": "proxy.example.com"}, isValid: false},
+		{name: "request timeout", env: map[string]string{"DFH_REQUEST_TIMEOUT": "1m30s"}, timeout: 90 * time.Second, isValid: true},
+		{name: "request timeout without unit", env: map[string]string{"DFH_REQUEST_TIMEOUT": "30"}, isValid: false},
+		{name: "negative request timeout", env: map[string]string{"DFH_REQUEST_TIMEOUT": "-1s"}, isValid: false},
+		{name: "TLS certificate without key", env: map[string]string{"DFH_TLS_CERT_FILE": "main.go"}, isValid: false},
+		{name: "TLS key without certificate", env: map[string]string{"DFH_TLS_KEY_FILE": "main.go"}, isValid: false},
+		{
+			name:    "non-existing TLS file",
+			env:     map[string]string{"DFH_TLS_CERT_FILE": "main.go", "DFH_TLS_KEY_FILE": "/does/not/exist"},
+			isValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Isolate the test from the environment of the caller.
+			for _, name := range configEnv {
+				t.Setenv(name, "")
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := loadConfig(nil)
+			if !tt.isValid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.origins, cfg.AllowedOrigins)
+			assert.Equal(t, tt.gzipLevel, cfg.GzipLevel)
+			assert.Equal(t, tt.rateLimit, cfg.RateLimit)
+			assert.Equal(t, tt.proxies, cfg.TrustedProxies)
+			assert.Equal(t, tt.timeout, cfg.RequestTimeout)
+		})
+	}
+}
+
+func TestSetupTracingPropagation(t *testing.T) {
+	// Record the spans in memory.
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// Install the propagator without exporting spans anywhere.
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	shutdown, err := setupTracing(context.Background())
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	// Boiler plate setup.
+	router := server.SetupRouter(server.Config{TracerProvider: provider})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/repos", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Span must continue the trace of the caller.
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].Parent().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+}
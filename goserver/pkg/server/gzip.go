@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultGzipMinSize is the smallest response body worth compressing.
+const defaultGzipMinSize = 1024
+
+/*
+gzipWriter buffers the response body so that `compress` can decide whether
+it is large enough to be worth compressing.
+
+Flushing stops the buffering and sends the body uncompressed, since the
+response headers cannot be changed anymore once they are on the wire.
+*/
+type gzipWriter struct {
+	gin.ResponseWriter
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *gzipWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buf.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.buf.WriteString(s)
+}
+
+func (w *gzipWriter) Flush() {
+	if !w.passthrough {
+		w.passthrough = true
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+	w.ResponseWriter.Flush()
+}
+
+/*
+acceptsGzip returns true if the `Accept-Encoding` header permits gzip.
+
+Encodings with a quality value of zero, eg "gzip;q=0", are refused. A
+"*" entry applies if gzip is not listed explicitly.
+*/
+func acceptsGzip(header string) bool {
+	gzipQ, wildcardQ := -1.0, -1.0
+	for _, entry := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(entry, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				var err error
+				if q, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+					q = 0
+				}
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip":
+			gzipQ = q
+		case "*":
+			wildcardQ = q
+		}
+	}
+
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return wildcardQ > 0
+}
+
+/*
+compress returns a middleware that gzip encodes response bodies of at least
+`minSize` bytes if the client accepts it.
+
+Smaller bodies are sent verbatim because the gzip overhead would outweigh
+the savings. So are the bodies of handlers that flush or send the headers
+early, eg to stream a response. A `minSize` of zero means
+`defaultGzipMinSize` and a `level` of zero means `gzip.DefaultCompression`.
+
+Panics if `level` is not supported by `compress/gzip`.
+*/
+func compress(level int, minSize int) gin.HandlerFunc {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		panic(err)
+	}
+	if minSize == 0 {
+		minSize = defaultGzipMinSize
+	}
+
+	return func(c *gin.Context) {
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		// Buffer the response until the handlers have finished. Restore the
+		// original writer even if a handler panics, so that the recovery
+		// middleware can still send its error response.
+		orig := c.Writer
+		w := &gzipWriter{ResponseWriter: orig}
+		c.Writer = w
+		defer func() { c.Writer = orig }()
+		c.Header("Vary", "Accept-Encoding")
+		c.Next()
+		c.Writer = orig
+
+		if orig.Written() || w.buf.Len() < minSize || orig.Header().Get("Content-Encoding") != "" {
+			_, _ = orig.Write(w.buf.Bytes())
+			return
+		}
+
+		// The level was already checked above, so this cannot fail.
+		gz, _ := gzip.NewWriterLevel(orig, level)
+		orig.Header().Set("Content-Encoding", "gzip")
+		orig.Header().Del("Content-Length")
+		_, _ = gz.Write(w.buf.Bytes())
+		_ = gz.Close()
+	}
+}
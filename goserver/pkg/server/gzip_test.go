@@ -0,0 +1,118 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	repos := `[{"name":"Repo 1"},{"name":"Repo 2"}]`
+	internalError := `{"code":500,"message":"internal server error"}`
+
+	tests := []struct {
+		name       string
+		path       string
+		minSize    int
+		encoding   string
+		code       int
+		body       string
+		isCompress bool
+	}{
+		{name: "gzip accepted", path: "/repos", minSize: 1, encoding: "gzip, deflate", code: http.StatusOK, body: repos, isCompress: true},
+		{name: "gzip with quality", path: "/repos", minSize: 1, encoding: "deflate, gzip;q=0.5", code: http.StatusOK, body: repos, isCompress: true},
+		{name: "wildcard accepted", path: "/repos", minSize: 1, encoding: "*", code: http.StatusOK, body: repos, isCompress: true},
+		{name: "gzip not accepted", path: "/repos", minSize: 1, encoding: "", code: http.StatusOK, body: repos, isCompress: false},
+		{name: "gzip refused", path: "/repos", minSize: 1, encoding: "gzip;q=0, deflate", code: http.StatusOK, body: repos, isCompress: false},
+		{name: "gzip refused despite wildcard", path: "/repos", minSize: 1, encoding: "*, gzip; q=0", code: http.StatusOK, body: repos, isCompress: false},
+		{name: "wildcard refused", path: "/repos", minSize: 1, encoding: "*;q=0", code: http.StatusOK, body: repos, isCompress: false},
+		{name: "below minimum size", path: "/repos", minSize: 0, encoding: "gzip", code: http.StatusOK, body: repos, isCompress: false},
+		{name: "handler panics", path: "/panic", minSize: 1, encoding: "gzip", code: http.StatusInternalServerError, body: internalError, isCompress: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Boiler plate setup.
+			router := SetupRouter(Config{GzipMinSize: tt.minSize})
+			router.GET("/panic", func(c *gin.Context) {
+				c.String(http.StatusOK, "partial response")
+				panic("bug")
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.encoding != "" {
+				req.Header.Set("Accept-Encoding", tt.encoding)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code)
+
+			// Decompress the body if necessary.
+			body := w.Body.Bytes()
+			if tt.isCompress {
+				assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+				gz, err := gzip.NewReader(w.Body)
+				assert.NoError(t, err)
+				defer gz.Close()
+
+				var payload json.RawMessage
+				assert.NoError(t, json.NewDecoder(gz).Decode(&payload))
+				body = payload
+			} else {
+				assert.Empty(t, w.Header().Get("Content-Encoding"))
+			}
+
+			// Body must be the expected JSON.
+			assert.JSONEq(t, tt.body, string(body))
+		})
+	}
+}
+
+func TestCompressStreaming(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+	}{
+		{
+			name: "flush",
+			handler: func(c *gin.Context) {
+				_, _ = c.Writer.WriteString("first chunk,")
+				c.Writer.Flush()
+				_, _ = c.Writer.WriteString("second chunk")
+			},
+		},
+		{
+			name: "headers sent early",
+			handler: func(c *gin.Context) {
+				c.Writer.WriteHeaderNow()
+				_, _ = c.Writer.WriteString("first chunk,second chunk")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Boiler plate setup.
+			router := SetupRouter(Config{GzipMinSize: 1})
+			router.GET("/stream", tt.handler)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/stream", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			router.ServeHTTP(w, req)
+
+			// Body must be sent uncompressed since the headers were already out.
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "first chunk,second chunk", w.Body.String())
+		})
+	}
+}
+
+func TestCompressInvalidLevel(t *testing.T) {
+	// Must refuse to build a router that would fail every gzip response.
+	assert.Panics(t, func() { SetupRouter(Config{GzipLevel: 10, GzipMinSize: 1}) })
+}
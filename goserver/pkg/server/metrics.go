@@ -0,0 +1,94 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// responseTimeHeader reports how long the server took to build the response.
+const responseTimeHeader = "X-Response-Time"
+
+/*
+timingWriter adds the `X-Response-Time` header right before the response
+headers are sent, ie once the handler starts writing the body.
+*/
+type timingWriter struct {
+	gin.ResponseWriter
+	start time.Time
+}
+
+func (w *timingWriter) setHeader() {
+	if !w.Written() {
+		w.Header().Set(responseTimeHeader, time.Since(w.start).String())
+	}
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Flush() {
+	w.setHeader()
+	w.ResponseWriter.Flush()
+}
+
+func (w *timingWriter) Write(data []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+/*
+requestTimer returns a middleware that records the request duration in
+`histogram` and in the `X-Response-Time` header.
+
+The duration includes the time spent by all subsequent middleware, eg to
+compress the response. The histogram is labelled with the route pattern,
+not the literal path, to keep the number of time series bounded.
+*/
+func requestTimer(histogram *prometheus.HistogramVec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orig := c.Writer
+		w := &timingWriter{ResponseWriter: orig, start: time.Now()}
+		c.Writer = w
+		defer func() { c.Writer = orig }()
+		c.Next()
+		c.Writer = orig
+
+		// Handlers that did not write a body leave the header to us.
+		w.setHeader()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		histogram.WithLabelValues(route).Observe(time.Since(w.start).Seconds())
+	}
+}
+
+/*
+newMetrics returns a middleware that measures the requests and a handler
+that serves the measurements to Prometheus.
+
+Every call creates its own Prometheus registry.
+*/
+func newMetrics() (gin.HandlerFunc, gin.HandlerFunc) {
+	registry := prometheus.NewRegistry()
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dfh_request_duration_seconds",
+		Help:    "Time taken to build the HTTP response.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+	registry.MustRegister(histogram)
+
+	handler := gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return requestTimer(histogram), handler
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseTimeHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		encoding string
+		code     int
+	}{
+		{name: "with body", path: "/repos", code: http.StatusOK},
+		{name: "compressed", path: "/repos", encoding: "gzip", code: http.StatusOK},
+		{name: "unknown route", path: "/does-not-exist", code: http.StatusNotFound},
+		{name: "flushed", path: "/stream", code: http.StatusOK},
+		{name: "handler panics", path: "/panic", code: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Boiler plate setup.
+			router := SetupRouter(Config{GzipMinSize: 1})
+			router.GET("/stream", func(c *gin.Context) {
+				c.Writer.Flush()
+				_, _ = c.Writer.WriteString("chunk")
+			})
+			router.GET("/panic", func(c *gin.Context) { panic("bug") })
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.encoding != "" {
+				req.Header.Set("Accept-Encoding", tt.encoding)
+			}
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.encoding, w.Header().Get("Content-Encoding"))
+
+			// Header must have been sent and contain a valid duration.
+			duration, err := time.ParseDuration(w.Result().Header.Get("X-Response-Time"))
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, duration, time.Duration(0))
+		})
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	// Boiler plate setup.
+	router := SetupRouter(Config{AuthToken: "secret"})
+	router.GET("/panic", func(c *gin.Context) { panic("bug") })
+
+	// Make authenticated requests to have something to measure.
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/repos", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Metrics must be available without authentication.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/metrics", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Must have recorded the request durations for both routes.
+	assert.Contains(t, w.Body.String(), `dfh_request_duration_seconds_count{route="/repos"} 1`)
+	assert.Contains(t, w.Body.String(), `dfh_request_duration_seconds_count{route="/panic"} 1`)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+rateLimiter counts the requests per client IP in fixed one minute windows.
+
+All clients share the same window boundaries, which means the counters can
+simply be discarded whenever a new window starts.
+*/
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	start  time.Time
+	counts map[string]int
+
+	// now returns the current time and is replaced in tests.
+	now func() time.Time
+}
+
+func newRateLimiter(limit int) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: time.Minute,
+		counts: map[string]int{},
+		now:    time.Now,
+	}
+}
+
+/*
+allow records a request from `ip` and returns true if it is within the limit.
+
+If not, it also returns the time until the current window ends.
+*/
+func (rl *rateLimiter) allow(ip string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	if now.Sub(rl.start) >= rl.window {
+		rl.start = now
+		clear(rl.counts)
+	}
+
+	if rl.counts[ip] >= rl.limit {
+		return false, rl.start.Add(rl.window).Sub(now)
+	}
+	rl.counts[ip]++
+	return true, 0
+}
+
+/*
+middleware rejects requests that exceed the limit with 429 (Too Many
+Requests) and a `Retry-After` header.
+
+Requests to `publicPaths` are never limited.
+*/
+func (rl *rateLimiter) middleware(c *gin.Context) {
+	if publicPaths[c.Request.URL.Path] {
+		c.Next()
+		return
+	}
+
+	ok, retry := rl.allow(c.ClientIP())
+	if !ok {
+		c.Header("Retry-After", fmt.Sprint(math.Ceil(retry.Seconds())))
+		abortWithError(c, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	// Boiler plate setup.
+	router := SetupRouter(Config{RateLimit: 2})
+	get := func(path string, ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// First two requests must succeed.
+	assert.Equal(t, http.StatusOK, get("/repos", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, get("/repos", "10.0.0.1").Code)
+
+	// Third request must be rejected with a hint when to retry.
+	w := get("/repos", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Other clients and public paths must be unaffected.
+	assert.Equal(t, http.StatusOK, get("/repos", "10.0.0.2").Code)
+	assert.Equal(t, http.StatusOK, get("/healthz", "10.0.0.1").Code)
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		codes   []int
+	}{
+		// Clients must not reset the limit with a different X-Forwarded-For per request.
+		{name: "untrusted proxy", proxies: nil, codes: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}},
+		// Each forwarded client must get its own limit behind a trusted proxy.
+		{name: "trusted proxy", proxies: []string{"10.0.0.0/8"}, codes: []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Boiler plate setup.
+			router := SetupRouter(Config{RateLimit: 1, TrustedProxies: tt.proxies})
+			for i, forwardedFor := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(http.MethodGet, "/repos", nil)
+				req.RemoteAddr = "10.0.0.1:1234"
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tt.codes[i], w.Code)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := newRateLimiter(1)
+	rl.now = func() time.Time { return now }
+
+	// Second request in the same window must be rejected.
+	ok, _ := rl.allow("10.0.0.1")
+	assert.True(t, ok)
+	now = now.Add(20 * time.Second)
+	ok, retry := rl.allow("10.0.0.1")
+	assert.False(t, ok)
+	assert.Equal(t, 40*time.Second, retry)
+
+	// Request must succeed again once the window has passed.
+	now = now.Add(40 * time.Second)
+	ok, _ = rl.allow("10.0.0.1")
+	assert.True(t, ok)
+}
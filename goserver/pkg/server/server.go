@@ -1,18 +1,271 @@
 package server
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// shutdownTimeout bounds how long in-flight requests may take to finish once
+// the server was asked to stop.
+const shutdownTimeout = 10 * time.Second
+
+// requestIDHeader carries the ID that correlates a request with its logs.
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID restricts client supplied request IDs to a safe charset and
+// length, since they end up in logs and response headers.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// publicPaths are exempt from authentication and rate limiting, eg for
+// liveness probes and metric scrapers.
+var publicPaths = map[string]bool{"/healthz": true, "/metrics": true}
+
+// Build information, set at link time, eg
+// go build -ldflags "-X workspaceApi/pkg/server.version=1.2.3".
+var (
+	version   = "dev"
+	gitCommit = ""
+	buildDate = ""
+)
+
+// defaultAllowedOrigins permits the Vite dev server of the frontend.
+var defaultAllowedOrigins = []string{"http://localhost:5000"}
+
+/*
+Config holds the settings for the API server.
+
+The zero value is valid and yields a server with default settings.
+*/
+type Config struct {
+	// Logger receives the structured request logs. Defaults to `slog.Default()`.
+	Logger *slog.Logger
+
+	// AllowedOrigins lists the origins permitted to make cross-origin
+	// requests. Defaults to the frontend dev server.
+	AllowedOrigins []string
+
+	// TLSCertFile and TLSKeyFile are the PEM encoded certificate and key.
+	// The server uses HTTPS if both are set and plain HTTP if neither is.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// AuthToken, if set, must be supplied by clients as a bearer token.
+	// Authentication is disabled if it is empty.
+	AuthToken string
+
+	// GzipLevel is the compression level for responses. Defaults to
+	// `gzip.DefaultCompression`.
+	GzipLevel int
+
+	// GzipMinSize is the smallest response body in bytes that will be
+	// compressed. Defaults to `defaultGzipMinSize`.
+	GzipMinSize int
+
+	// RateLimit is the maximum number of requests per minute and client IP.
+	// Rate limiting is disabled if it is zero.
+	RateLimit int
+
+	// TrustedProxies lists the IPs and CIDRs of the reverse proxies whose
+	// X-Forwarded-For header determines the client IP. Defaults to none, ie
+	// the client IP is always the remote address of the connection.
+	TrustedProxies []string
+
+	// BasePath is the prefix for all API routes, eg "/api/v1". The
+	// operational endpoints "/healthz", "/version" and "/metrics" always
+	// remain at the root. Defaults to the root path.
+	BasePath string
+
+	// TracerProvider creates a span for every request. Defaults to the
+	// global OpenTelemetry provider, which is a no-op unless configured.
+	TracerProvider trace.TracerProvider
+
+	// RequestTimeout cancels the context of requests that take longer.
+	// Timeouts are disabled if it is zero.
+	RequestTimeout time.Duration
+}
+
+/* useTLS returns true if `cfg` specifies a TLS certificate and key.*/
+func (cfg Config) useTLS() bool {
+	return cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+}
+
+/*
+Validate returns an error if `cfg` is unusable.
+
+Every allowed origin must start with a scheme, ie "http://" or "https://".
+Wildcards are not supported. The TLS certificate and key must either both
+be set and exist, or both be empty. The gzip level must be one of the
+levels supported by `compress/gzip`. The gzip minimum size, rate limit and
+request timeout must not be negative and every trusted proxy must be an IP
+or CIDR.
+*/
+func (cfg Config) Validate() error {
+	for _, origin := range cfg.AllowedOrigins {
+		if strings.Contains(origin, "*") {
+			return fmt.Errorf("invalid origin %q: wildcards are not supported", origin)
+		}
+	}
+	if err := cfg.corsConfig().Validate(); err != nil {
+		return err
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return errors.New("TLS requires both a certificate and a key file")
+	}
+	for _, fname := range []string{cfg.TLSCertFile, cfg.TLSKeyFile} {
+		if fname == "" {
+			continue
+		}
+		if _, err := os.Stat(fname); err != nil {
+			return fmt.Errorf("invalid TLS file: %w", err)
+		}
+	}
+
+	if cfg.GzipLevel < gzip.HuffmanOnly || cfg.GzipLevel > gzip.BestCompression {
+		return fmt.Errorf("invalid gzip level %d: must be between %d and %d",
+			cfg.GzipLevel, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+	if cfg.GzipMinSize < 0 {
+		return fmt.Errorf("invalid gzip minimum size %d: must not be negative", cfg.GzipMinSize)
+	}
+
+	if cfg.RateLimit < 0 {
+		return fmt.Errorf("invalid rate limit %d: must not be negative", cfg.RateLimit)
+	}
+	if cfg.RequestTimeout < 0 {
+		return fmt.Errorf("invalid request timeout %s: must not be negative", cfg.RequestTimeout)
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: must be an IP or CIDR", proxy)
+		}
+	}
+	return nil
+}
+
+/* corsConfig returns the CORS settings for the origins in `cfg`.*/
+func (cfg Config) corsConfig() cors.Config {
+	origins := cfg.AllowedOrigins
+	if len(origins) == 0 {
+		origins = defaultAllowedOrigins
+	}
+	corsCfg := cors.DefaultConfig()
+	corsCfg.AllowOrigins = origins
+	corsCfg.AddAllowHeaders("Authorization", requestIDHeader)
+	corsCfg.AddExposeHeaders(requestIDHeader)
+	return corsCfg
+}
+
+/*
+allowCORS returns a middleware that adds the CORS headers for the origins
+permitted by `cfg`.
+
+Preflight requests from other origins are rejected with 403 (Forbidden).
+Their actual requests are served without CORS headers, ie the browser
+withholds the response from the foreign page. This keeps same-origin
+requests working behind reverse proxies, whose browsers also send an
+`Origin` header.
+*/
+func allowCORS(cfg cors.Config) gin.HandlerFunc {
+	allowed := map[string]bool{}
+	for _, origin := range cfg.AllowOrigins {
+		allowed[origin] = true
+	}
+	handler := cors.New(cfg)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		switch {
+		case origin == "" || allowed[origin]:
+			handler(c)
+		case c.Request.Method == http.MethodOptions:
+			abortWithError(c, http.StatusForbidden, "origin not allowed")
+		default:
+			c.Next()
+		}
+	}
+}
+
+/*
+APIError is the JSON body of every error response.
+
+Clients can rely on this shape for all non-2xx responses, eg
+{"code": 404, "message": "not found"}.
+*/
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+/* abortWithError aborts the request and responds with an `APIError`.*/
+func abortWithError(c *gin.Context, code int, message string) {
+	c.AbortWithStatusJSON(code, APIError{Code: code, Message: message})
+}
+
+/* Health is the response body of the health endpoint.*/
+type Health struct {
+	Status string  `json:"status"`
+	Uptime float64 `json:"uptime"` // in seconds
+}
+
+/* BuildInfo is the response body of the version endpoint.*/
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
 type Repository struct {
 	Name string `json:"name"`
 }
 
-/* getHealthz unconditionally returns a 200 response.*/
-func getHealthz(c *gin.Context) {
-	c.JSON(http.StatusOK, nil)
+/*
+getHealthz returns a handler that unconditionally returns a 200 response.
+
+The body reports the time between `start` and `now()`, eg
+{"status": "ok", "uptime": 12.3}.
+*/
+func getHealthz(start time.Time, now func() time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, Health{
+			Status: "ok",
+			Uptime: now().Sub(start).Seconds(),
+		})
+	}
+}
+
+/* getVersion returns the build information of the running binary.*/
+func getVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, BuildInfo{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	})
 }
 
 /*
@@ -39,19 +292,232 @@ Returns 400 (Bad Request) if the payload is invalid.
 func postRepository(c *gin.Context) {
 	var payload Repository
 	if err := c.BindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, nil)
 }
 
+/*
+requestID is a middleware that assigns an ID to every request.
+
+It reuses the ID from the `X-Request-ID` header if the client supplied a
+valid one and generates a random one otherwise. The ID is stored in the
+context under `requestIDHeader` and echoed back in the response header.
+*/
+func requestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if !validRequestID.MatchString(id) {
+		buf := make([]byte, 16)
+		_, _ = rand.Read(buf)
+		id = hex.EncodeToString(buf)
+	}
+	c.Set(requestIDHeader, id)
+	c.Header(requestIDHeader, id)
+	c.Next()
+}
+
+/*
+bearerAuth returns a middleware that rejects requests without a valid
+`Authorization: Bearer <token>` header with 401 (Unauthorized).
+
+The scheme is case-insensitive as per RFC 7235. Requests to `publicPaths`
+are always permitted.
+*/
+func bearerAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if publicPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		scheme, supplied, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			abortWithError(c, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		c.Next()
+	}
+}
+
+/*
+requestTimeout returns a middleware that cancels the request context after
+`timeout`.
+
+Handlers must honour the context. If the deadline expired before the
+handler wrote a response, the middleware responds with 503 (Service
+Unavailable).
+*/
+func requestTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			abortWithError(c, http.StatusServiceUnavailable, "request timed out")
+		}
+	}
+}
+
+/*
+requestLogger returns a middleware that logs every request to `logger`.
+
+Each log entry carries the method, path, status code, latency and request
+ID as separate attributes so that log aggregators can filter on them.
+*/
+func requestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		logger.Info("request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start),
+			"client", c.ClientIP(),
+			"request_id", c.GetString(requestIDHeader),
+		)
+	}
+}
+
+/*
+recovery returns a middleware that turns panics into 500 (Internal Server
+Error) responses.
+
+The panic is logged to `logger` instead of being written as plain text to
+stderr.
+*/
+func recovery(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		logger.Error("panic",
+			"error", err,
+			"path", c.Request.URL.Path,
+			"request_id", c.GetString(requestIDHeader),
+		)
+		abortWithError(c, http.StatusInternalServerError, "internal server error")
+	})
+}
+
 /*
 SetupRouter adds the handlers and returns the configured `gin.Engine` routing object.
+
+Routers do not share any state, not even metrics, which means several of
+them can coexist in the same process.
+
+Panics if `cfg` is invalid, see `Config.Validate`.
 */
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
-	router.GET("/healthz", getHealthz)
-	router.GET("/repos", getRepositories)
-	router.POST("/repos", postRepository)
+func SetupRouter(cfg Config) *gin.Engine {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	tracerProvider := cfg.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	// Only honour X-Forwarded-For from trusted proxies, so that clients
+	// cannot choose their own IP to evade the rate limit.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(err)
+	}
+	router.Use(otelgin.Middleware("dfh", otelgin.WithTracerProvider(tracerProvider)))
+
+	// Time the requests outside of `recovery` and `compress` so that the
+	// measurements include panicking handlers and the compression.
+	timer, metrics := newMetrics()
+	router.Use(
+		requestID,
+		requestLogger(logger),
+		timer,
+		recovery(logger),
+		allowCORS(cfg.corsConfig()),
+		compress(cfg.GzipLevel, cfg.GzipMinSize),
+	)
+	if cfg.RateLimit > 0 {
+		router.Use(newRateLimiter(cfg.RateLimit).middleware)
+	}
+	if cfg.AuthToken != "" {
+		router.Use(bearerAuth(cfg.AuthToken))
+	}
+	if cfg.RequestTimeout > 0 {
+		router.Use(requestTimeout(cfg.RequestTimeout))
+	}
+	router.NoRoute(func(c *gin.Context) {
+		abortWithError(c, http.StatusNotFound, "not found")
+	})
+	router.NoMethod(func(c *gin.Context) {
+		abortWithError(c, http.StatusMethodNotAllowed, "method not allowed")
+	})
+	router.GET("/healthz", getHealthz(time.Now(), time.Now))
+	router.GET("/version", getVersion)
+	router.GET("/metrics", metrics)
+
+	// Normalise the base path to "/" or "/prefix" without a trailing slash.
+	api := router.Group("/" + strings.Trim(cfg.BasePath, "/"))
+	api.GET("/repos", getRepositories)
+	api.POST("/repos", postRepository)
 	return router
 }
+
+/*
+Run listens on `addr` and serves the API configured by `cfg` until `ctx` is
+cancelled.
+
+See `Serve` for the shutdown semantics.
+*/
+func Run(ctx context.Context, cfg Config, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, cfg, listener)
+}
+
+/*
+Serve accepts connections on `listener` until `ctx` is cancelled.
+
+The server uses HTTPS if `cfg` specifies a certificate and key file.
+Returns an error without serving if `cfg` is invalid, see `Config.Validate`.
+
+Cancelling the context gracefully shuts down the HTTP server, ie it stops
+accepting new connections and waits up to `shutdownTimeout` for in-flight
+requests to complete. Returns nil after a clean shutdown.
+*/
+func Serve(ctx context.Context, cfg Config, listener net.Listener) error {
+	if err := cfg.Validate(); err != nil {
+		listener.Close()
+		return err
+	}
+	srv := &http.Server{Handler: SetupRouter(cfg)}
+
+	// Shut down the server once the context is cancelled. The derived context
+	// also stops this goroutine if serving fails for any other reason.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		done <- srv.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if cfg.useTLS() {
+		err = srv.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = srv.Serve(listener)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-done
+}
@@ -2,27 +2,75 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/goleak"
 )
 
 func TestGetHealthz(t *testing.T) {
 	// Boiler plate setup.
-	router := SetupRouter()
+	router := SetupRouter(Config{})
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
 	router.ServeHTTP(w, req)
 
+	// Request must succeed and report the uptime.
 	assert.Equal(t, http.StatusOK, w.Code)
+	var payload Health
+	err := json.Unmarshal(w.Body.Bytes(), &payload)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", payload.Status)
+	assert.GreaterOrEqual(t, payload.Uptime, 0.0)
+}
+
+func TestGetVersion(t *testing.T) {
+	// Boiler plate setup.
+	router := SetupRouter(Config{})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/version", nil)
+	router.ServeHTTP(w, req)
+
+	// Request must succeed and return all build info fields.
+	assert.Equal(t, http.StatusOK, w.Code)
+	var payload map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &payload)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"version":   "dev",
+		"gitCommit": "",
+		"buildDate": "",
+		"goVersion": runtime.Version(),
+	}, payload)
 }
 
 func TestGetRepositories(t *testing.T) {
 	// Boiler plate setup.
-	router := SetupRouter()
+	router := SetupRouter(Config{})
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/repos", nil)
 	router.ServeHTTP(w, req)
@@ -56,7 +104,7 @@ func TestPostRepository(t *testing.T) {
 			assert.NoError(t, err)
 
 			// Boiler plate setup.
-			router := SetupRouter()
+			router := SetupRouter(Config{})
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest(http.MethodPost, "/repos", &buf)
 			router.ServeHTTP(w, req)
@@ -64,9 +112,598 @@ func TestPostRepository(t *testing.T) {
 			// Request must succeed.
 			if tt.isValid {
 				assert.Equal(t, http.StatusOK, w.Code)
+				return
+			}
+
+			// Invalid requests must return an error envelope.
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var apiErr APIError
+			err = json.Unmarshal(w.Body.Bytes(), &apiErr)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+			assert.NotEmpty(t, apiErr.Message)
+		})
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	// Must not leak the shutdown goroutine or any connection handlers.
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	// Run the server in the background.
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var serveErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serveErr = Serve(ctx, Config{}, listener)
+	}()
+
+	// Server must respond to requests.
+	resp, err := http.Get("http://" + listener.Addr().String() + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Cancelling the context must stop the server.
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	assert.NoError(t, serveErr)
+
+	// Server must not accept new connections anymore.
+	_, err = http.Get("http://" + listener.Addr().String() + "/healthz")
+	assert.Error(t, err)
+}
+
+func TestServeError(t *testing.T) {
+	// Must not leak the shutdown goroutine.
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	// Serving on a closed listener must fail immediately.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener.Close()
+	err = Serve(context.Background(), Config{}, listener)
+	assert.Error(t, err)
+}
+
+func TestRequestLogger(t *testing.T) {
+	// Capture the logs in a buffer.
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// Boiler plate setup.
+	router := SetupRouter(Config{Logger: logger})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/repos", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Must have logged exactly one JSON entry with the request details.
+	var entry map[string]any
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	assert.NoError(t, err)
+	assert.Equal(t, "request", entry["msg"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/repos", entry["path"])
+	assert.Equal(t, float64(http.StatusOK), entry["status"])
+	assert.Contains(t, entry, "latency")
+	assert.Equal(t, w.Header().Get("X-Request-ID"), entry["request_id"])
+}
+
+func TestRecovery(t *testing.T) {
+	// Capture the logs in a buffer.
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// Boiler plate setup.
+	router := SetupRouter(Config{Logger: logger})
+	router.GET("/panic", func(c *gin.Context) { panic("bug") })
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+	router.ServeHTTP(w, req)
+
+	// Must respond with an error envelope.
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"code":500,"message":"internal server error"}`, w.Body.String())
+
+	// Must have logged the panic as JSON.
+	var entry map[string]any
+	line, _, _ := bytes.Cut(buf.Bytes(), []byte("\n"))
+	err := json.Unmarshal(line, &entry)
+	require.NoError(t, err)
+	assert.Equal(t, "panic", entry["msg"])
+	assert.Equal(t, "bug", entry["error"])
+	assert.Equal(t, "/panic", entry["path"])
+	assert.Equal(t, w.Header().Get("X-Request-ID"), entry["request_id"])
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+	}{
+		{name: "unknown route", method: http.MethodGet, path: "/does-not-exist", code: http.StatusNotFound},
+		{name: "unsupported method", method: http.MethodDelete, path: "/repos", code: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Boiler plate setup.
+			router := SetupRouter(Config{})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			// Must return the status code also in the JSON body.
+			assert.Equal(t, tt.code, w.Code)
+			var apiErr APIError
+			err := json.Unmarshal(w.Body.Bytes(), &apiErr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, apiErr.Code)
+			assert.NotEmpty(t, apiErr.Message)
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name      string
+		origins   []string
+		origin    string
+		isAllowed bool
+	}{
+		{name: "default origin", origins: nil, origin: "http://localhost:5000", isAllowed: true},
+		{name: "configured origin", origins: []string{"https://dfh.example.com"}, origin: "https://dfh.example.com", isAllowed: true},
+		{name: "unknown origin", origins: []string{"https://dfh.example.com"}, origin: "https://evil.example.com", isAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Boiler plate setup.
+			router := SetupRouter(Config{AllowedOrigins: tt.origins})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodOptions, "/repos", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			router.ServeHTTP(w, req)
+
+			// Preflight must only succeed for permitted origins.
+			if !tt.isAllowed {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+				var apiErr APIError
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+				assert.Equal(t, http.StatusForbidden, apiErr.Code)
+				assert.NotEmpty(t, apiErr.Message)
+			} else {
+				assert.Equal(t, http.StatusNoContent, w.Code)
+				assert.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+				assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+			}
+
+			// Actual requests must always be served, but only carry the CORS
+			// headers for permitted origins.
+			w = httptest.NewRecorder()
+			req, _ = http.NewRequest(http.MethodGet, "/repos", nil)
+			req.Header.Set("Origin", tt.origin)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `[{"name":"Repo 1"},{"name":"Repo 2"}]`, w.Body.String())
+			if tt.isAllowed {
+				assert.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+			} else {
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		isValid bool
+	}{
+		{name: "zero value", cfg: Config{}, isValid: true},
+		{name: "valid origins", cfg: Config{AllowedOrigins: []string{"http://localhost:5000", "https://dfh.example.com"}}, isValid: true},
+		{name: "origin without scheme", cfg: Config{AllowedOrigins: []string{"dfh.example.com"}}, isValid: false},
+		{name: "wildcard origin", cfg: Config{AllowedOrigins: []string{"*"}}, isValid: false},
+		{name: "wildcard subdomain", cfg: Config{AllowedOrigins: []string{"https://*.example.com"}}, isValid: false},
+		{name: "best compression", cfg: Config{GzipLevel: 9}, isValid: true},
+		{name: "invalid gzip level", cfg: Config{GzipLevel: 10}, isValid: false},
+		{name: "negative gzip minimum size", cfg: Config{GzipMinSize: -1}, isValid: false},
+		{name: "negative rate limit", cfg: Config{RateLimit: -1}, isValid: false},
+		{name: "negative request timeout", cfg: Config{RequestTimeout: -time.Second}, isValid: false},
+		{name: "trusted proxies", cfg: Config{TrustedProxies: []string{"10.0.0.1", "192.168.0.0/16"}}, isValid: true},
+		{name: "invalid trusted proxy", cfg: Config{TrustedProxies: []string{"proxy.example.com"}}, isValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.isValid {
+				assert.NoError(t, err)
 			} else {
-				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestSetupRouterInvalidTrustedProxy(t *testing.T) {
+	// Must not silently ignore unparseable proxies.
+	assert.Panics(t, func() { SetupRouter(Config{TrustedProxies: []string{"bogus"}}) })
+}
+
+func TestRequestID(t *testing.T) {
+	// Boiler plate setup.
+	router := SetupRouter(Config{})
+
+	// Must generate a request ID if the client did not supply one.
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+	router.ServeHTTP(w, req)
+	assert.Len(t, w.Header().Get("X-Request-ID"), 32)
+
+	// Every request must get its own ID.
+	w2 := httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/healthz", nil)
+	router.ServeHTTP(w2, req)
+	assert.NotEqual(t, w.Header().Get("X-Request-ID"), w2.Header().Get("X-Request-ID"))
+
+	// Must preserve a client supplied request ID.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-ID", "my-request-id")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "my-request-id", w.Header().Get("X-Request-ID"))
+
+	// Must replace oversized or malformed request IDs.
+	for _, id := range []string{strings.Repeat("a", 65), "bad id", "id\x00", "<script>"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("X-Request-ID", id)
+		router.ServeHTTP(w, req)
+		assert.NotEqual(t, id, w.Header().Get("X-Request-ID"))
+		assert.Len(t, w.Header().Get("X-Request-ID"), 32)
+	}
+
+	// Cross-origin clients must be able to send and read the request ID.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodOptions, "/repos", nil)
+	req.Header.Set("Origin", "http://localhost:5000")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "x-request-id")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "X-Request-Id")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/repos", nil)
+	req.Header.Set("Origin", "http://localhost:5000")
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Request-Id")
+}
+
+/*
+writeSelfSignedCert creates a self-signed certificate for 127.0.0.1.
+
+Returns the paths of the PEM encoded certificate and key, as well as the
+parsed certificate.
+*/
+func writeSelfSignedCert(t *testing.T) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dfh-test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	assert.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	assert.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	return certFile, keyFile, cert
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, cert := writeSelfSignedCert(t)
+	cfg := Config{TLSCertFile: certFile, TLSKeyFile: keyFile}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	// Run the server in the background.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	var serveErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serveErr = Serve(ctx, cfg, listener)
+	}()
+
+	// Client must only trust our self-signed certificate.
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+
+	// HTTPS request must succeed.
+	resp, err := client.Get("https://" + listener.Addr().String() + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Server must shut down cleanly.
+	cancel()
+	wg.Wait()
+	assert.NoError(t, serveErr)
+}
+
+func TestRunInvalidTLSConfig(t *testing.T) {
+	certFile, keyFile, _ := writeSelfSignedCert(t)
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "missing key", cfg: Config{TLSCertFile: certFile}},
+		{name: "missing certificate", cfg: Config{TLSKeyFile: keyFile}},
+		{name: "non-existing file", cfg: Config{TLSCertFile: certFile, TLSKeyFile: "/does/not/exist"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Must return an error instead of serving.
+			err := Run(context.Background(), tt.cfg, "127.0.0.1:0")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBearerAuthPreflight(t *testing.T) {
+	// Boiler plate setup.
+	router := SetupRouter(Config{AuthToken: "secret"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodOptions, "/repos", nil)
+	req.Header.Set("Origin", "http://localhost:5000")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+	router.ServeHTTP(w, req)
+
+	// Preflight must succeed without a token and permit the auth header.
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+
+	// Actual cross-origin requests must succeed with the token.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/repos", nil)
+	req.Header.Set("Origin", "http://localhost:5000")
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBearerAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		code   int
+	}{
+		{name: "valid token", path: "/repos", header: "Bearer secret", code: http.StatusOK},
+		{name: "missing token", path: "/repos", header: "", code: http.StatusUnauthorized},
+		{name: "wrong token", path: "/repos", header: "Bearer wrong", code: http.StatusUnauthorized},
+		{name: "lowercase scheme", path: "/repos", header: "bearer secret", code: http.StatusOK},
+		{name: "wrong scheme", path: "/repos", header: "Basic secret", code: http.StatusUnauthorized},
+		{name: "public path", path: "/healthz", header: "", code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Boiler plate setup.
+			router := SetupRouter(Config{AuthToken: "secret"})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
 			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
 		})
 	}
 }
+
+func TestBasePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		basePath string
+		path     string
+		code     int
+	}{
+		{name: "default base path", basePath: "", path: "/repos", code: http.StatusOK},
+		{name: "custom base path", basePath: "/api/v1", path: "/api/v1/repos", code: http.StatusOK},
+		{name: "trailing slash", basePath: "api/v1/", path: "/api/v1/repos", code: http.StatusOK},
+		{name: "old path", basePath: "/api/v1", path: "/repos", code: http.StatusNotFound},
+		{name: "health unaffected", basePath: "/api/v1", path: "/healthz", code: http.StatusOK},
+		{name: "version unaffected", basePath: "/api/v1", path: "/version", code: http.StatusOK},
+		{name: "metrics unaffected", basePath: "/api/v1", path: "/metrics", code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Boiler plate setup.
+			router := SetupRouter(Config{BasePath: tt.basePath})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestTracing(t *testing.T) {
+	// Record the spans in memory.
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// Boiler plate setup.
+	router := SetupRouter(Config{TracerProvider: provider})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/repos", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Must have created exactly one span for the route.
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "/repos", spans[0].Name())
+}
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		delay time.Duration
+		code  int
+	}{
+		{name: "fast handler", delay: 0, code: http.StatusOK},
+		{name: "slow handler", delay: time.Second, code: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Add a handler that takes `delay` unless the request is cancelled.
+			router := SetupRouter(Config{RequestTimeout: 20 * time.Millisecond})
+			router.GET("/slow", func(c *gin.Context) {
+				select {
+				case <-time.After(tt.delay):
+					c.JSON(http.StatusOK, nil)
+				case <-c.Request.Context().Done():
+				}
+			})
+
+			// Boiler plate setup.
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/slow", nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+}
+
+func TestIndependentServers(t *testing.T) {
+	// Start two servers with different configurations in the same process.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	serveErrs := make([]error, 2)
+	addrs := []string{}
+	for i, cfg := range []Config{{BasePath: "/a"}, {BasePath: "/b"}} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		wg.Add(1)
+		go func(i int, cfg Config) {
+			defer wg.Done()
+			serveErrs[i] = Serve(ctx, cfg, listener)
+		}(i, cfg)
+		addrs = append(addrs, "http://"+listener.Addr().String())
+	}
+
+	get := func(url string) (int, []byte) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		_, err = body.ReadFrom(resp.Body)
+		assert.NoError(t, err)
+		return resp.StatusCode, body.Bytes()
+	}
+
+	// Each server must only serve its own base path.
+	code, _ := get(addrs[0] + "/a/repos")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = get(addrs[0] + "/b/repos")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = get(addrs[1] + "/b/repos")
+	assert.Equal(t, http.StatusOK, code)
+
+	// Each server must only count its own requests.
+	_, metrics := get(addrs[0] + "/metrics")
+	assert.Contains(t, string(metrics), `dfh_request_duration_seconds_count{route="/a/repos"} 1`)
+	assert.NotContains(t, string(metrics), `route="/b/repos"`)
+
+	// Both servers must shut down cleanly.
+	cancel()
+	wg.Wait()
+	assert.NoError(t, serveErrs[0])
+	assert.NoError(t, serveErrs[1])
+}
+
+func TestIndependentUptime(t *testing.T) {
+	// Mount two health handlers that started at different times on routers
+	// that share a fake clock.
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	routers := []*gin.Engine{gin.New(), gin.New()}
+	routers[0].GET("/healthz", getHealthz(now.Add(-30*time.Second), clock))
+	routers[1].GET("/healthz", getHealthz(now.Add(-10*time.Second), clock))
+
+	// Each router must track its own uptime.
+	for i, expected := range []float64{30, 10} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+		routers[i].ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var health Health
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+		assert.Equal(t, expected, health.Uptime)
+	}
+
+	// Uptime must advance with the clock.
+	now = now.Add(5 * time.Second)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+	routers[1].ServeHTTP(w, req)
+	var health Health
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, 15.0, health.Uptime)
+}